@@ -18,6 +18,8 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -34,9 +36,50 @@ import (
 )
 
 var (
-	cache = flag.String("cache", "", "File cache of the .json schema")
+	cache      = flag.String("cache", "", "File cache of the primary .json schema")
+	schemas    schemaFlags
+	updateLock = flag.Bool("update-lock", false, "Fetch schemas regardless of drift and rewrite schema.lock.json with their ref and digest")
+	printLock  = flag.Bool("print-lock", false, "Print the contents of schema.lock.json and exit without generating any files")
+	lang       = flag.String("lang", "cpp", "Comma-separated list of backends to generate: cpp, go")
 )
 
+func init() {
+	flag.Var(&schemas, "schema", "DAP schema to generate from (URL or path). "+
+		"May be repeated to merge in vendor extensions, e.g. "+
+		"-schema=debugProtocol.json -schema=delve=delve.json. "+
+		"The first occurrence without a 'name=' prefix is the upstream schema; "+
+		"defaults to the upstream DAP schema if omitted.")
+}
+
+// schemaFlag is a single -schema flag value. A bare value (no '=' prefix) is
+// the upstream schema. A "name=value" value is a vendor extension schema,
+// whose definitions are merged into the upstream ones and whose emitted
+// struct implementations are routed to a file suffixed with name.
+type schemaFlag struct {
+	name string
+	src  string
+}
+
+// schemaFlags collects repeated -schema flags.
+type schemaFlags []schemaFlag
+
+func (s *schemaFlags) String() string {
+	parts := make([]string, len(*s))
+	for i, f := range *s {
+		parts[i] = f.src
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s *schemaFlags) Set(v string) error {
+	name, src := "", v
+	if i := strings.IndexByte(v, '='); i >= 0 {
+		name, src = v[:i], v[i+1:]
+	}
+	*s = append(*s, schemaFlag{name: name, src: src})
+	return nil
+}
+
 const (
 	jsonURL = "https://raw.githubusercontent.com/microsoft/vscode-debugadapter-node/master/debugProtocol.json"
 
@@ -96,6 +139,142 @@ namespace dap {
 `
 )
 
+// lockEntry pins a single schema to a reproducible input: the concrete
+// upstream commit SHA it was fetched at (branches and tags are resolved to
+// a commit SHA by resolveCommitSHA before being pinned), and the expected
+// SHA-256 digest of the fetched JSON.
+type lockEntry struct {
+	Ref    string `json:"ref"`
+	SHA256 string `json:"sha256"`
+}
+
+// lockFile is the contents of schema.lock.json: one lockEntry per schema,
+// keyed by its -schema name ("" for the upstream schema).
+type lockFile map[string]lockEntry
+
+func lockFilePath() string {
+	_, thisFile, _, _ := runtime.Caller(0)
+	return path.Join(path.Dir(thisFile), "schema.lock.json")
+}
+
+func loadLockFile(path string) (lockFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return lockFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lf := lockFile{}
+	if err := json.Unmarshal(data, &lf); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return lf, nil
+}
+
+func (lf lockFile) save(path string) error {
+	data, err := json.MarshalIndent(lf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func schemaDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func schemaLabel(name string) string {
+	if name == "" {
+		return "upstream"
+	}
+	return name
+}
+
+func isURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+// refOf extracts the ref (commit SHA or tag) component of a
+// raw.githubusercontent.com URL, e.g. ".../owner/repo/<ref>/path" -> "<ref>".
+// It returns "" for anything else, including local file paths.
+func refOf(src string) string {
+	const prefix = "https://raw.githubusercontent.com/"
+	if !strings.HasPrefix(src, prefix) {
+		return ""
+	}
+	parts := strings.SplitN(strings.TrimPrefix(src, prefix), "/", 4)
+	if len(parts) < 4 {
+		return ""
+	}
+	return parts[2]
+}
+
+// isCommitSHA reports whether ref looks like a full git commit SHA (40 hex
+// characters), as opposed to a moving branch or tag name.
+func isCommitSHA(ref string) bool {
+	if len(ref) != 40 {
+		return false
+	}
+	for _, c := range ref {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveCommitSHA resolves ref (a branch, tag, or already-concrete commit
+// SHA) on the raw.githubusercontent.com URL src to the concrete commit SHA
+// it currently points at, via the GitHub REST API. This lets -update-lock
+// pin schema.lock.json to the commit a schema was actually fetched at,
+// rather than to a moving branch name like "master".
+func resolveCommitSHA(src, ref string) (string, error) {
+	const prefix = "https://raw.githubusercontent.com/"
+	if isCommitSHA(ref) || !strings.HasPrefix(src, prefix) {
+		return ref, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(src, prefix), "/", 4)
+	if len(parts) < 4 {
+		return ref, nil
+	}
+	owner, repo := parts[0], parts[1]
+	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/commits/%s", owner, repo, ref)
+	resp, err := http.Get(apiURL)
+	if err != nil {
+		return "", fmt.Errorf("resolving ref '%s': %v", ref, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolving ref '%s': GitHub API returned %s", ref, resp.Status)
+	}
+	var commit struct {
+		SHA string `json:"sha"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
+		return "", fmt.Errorf("resolving ref '%s': %v", ref, err)
+	}
+	if commit.SHA == "" {
+		return "", fmt.Errorf("resolving ref '%s': GitHub API response missing a sha", ref)
+	}
+	return commit.SHA, nil
+}
+
+// pinnedSrc rewrites the ref component of a raw.githubusercontent.com URL to
+// ref. Anything else (including local file paths) is returned unchanged.
+func pinnedSrc(src, ref string) string {
+	const prefix = "https://raw.githubusercontent.com/"
+	if ref == "" || !strings.HasPrefix(src, prefix) {
+		return src
+	}
+	parts := strings.SplitN(strings.TrimPrefix(src, prefix), "/", 4)
+	if len(parts) < 4 {
+		return src
+	}
+	return prefix + parts[0] + "/" + parts[1] + "/" + ref + "/" + parts[3]
+}
+
 func main() {
 	flag.Parse()
 	if err := run(); err != nil {
@@ -146,6 +325,72 @@ type definition struct {
 	Required    []string     `json:"required"`
 	AllOf       []definition `json:"allOf"`
 	Ref         string       `json:"$ref"`
+
+	// source is the name of the vendor extension schema this definition was
+	// read from or last extended by, or "" for the upstream schema. It is not
+	// part of the JSON schema itself; it is stamped on after decoding, during
+	// mergeRoot.
+	source string `json:"-"`
+}
+
+// mergeRoot merges the definitions of src into dst, stamping each of src's
+// definitions with the given source name. Definitions not yet present in dst
+// are added as-is. Definitions present in both are merged: new properties and
+// allOf entries from src are appended, and a differing "type" is treated as
+// an unmergeable collision.
+func mergeRoot(dst *root, src *root, source string) error {
+	if dst.Definitions == nil {
+		dst.Definitions = map[string]definition{}
+	}
+	for _, entry := range src.definitions() {
+		def := entry.def
+		def.source = source
+		base, ok := dst.Definitions[entry.name]
+		if !ok {
+			dst.Definitions[entry.name] = def
+			continue
+		}
+		merged, err := mergeDefinition(entry.name, base, def)
+		if err != nil {
+			return err
+		}
+		dst.Definitions[entry.name] = merged
+	}
+	return nil
+}
+
+// mergeDefinition merges the vendor extension definition ext into the
+// already-known definition base, both named name. It returns an error if the
+// two definitions describe incompatible types.
+func mergeDefinition(name string, base, ext definition) (definition, error) {
+	if base.Ty != "" && ext.Ty != "" && base.Ty != ext.Ty {
+		return definition{}, fmt.Errorf("schema merge collision for '%s': type '%s' conflicts with '%s'", name, base.Ty, ext.Ty)
+	}
+
+	if base.Properties == nil && len(ext.Properties) > 0 {
+		base.Properties = properties{}
+	}
+	for propName, prop := range ext.Properties {
+		if existing, ok := base.Properties[propName]; ok {
+			if !reflect.DeepEqual(existing, prop) {
+				return definition{}, fmt.Errorf("schema merge collision for '%s.%s': incompatible property definitions", name, propName)
+			}
+			continue
+		}
+		base.Properties[propName] = prop
+	}
+
+	base.Required = append(base.Required, ext.Required...)
+	base.AllOf = append(base.AllOf, ext.AllOf...)
+
+	if base.Description == "" {
+		base.Description = ext.Description
+	}
+	if ext.source != "" {
+		base.source = ext.source
+	}
+
+	return base, nil
 }
 
 type properties map[string]property
@@ -273,6 +518,9 @@ type cppStruct struct {
 	emit     bool
 	typedefs []cppTypedef
 	ty       structType
+	// source is the vendor extension schema this struct was defined or
+	// extended by, or "" if it comes solely from the upstream schema.
+	source string
 }
 
 type cppTypedef struct {
@@ -382,6 +630,170 @@ func (s *cppStruct) writeCPP(w io.Writer) {
 	io.WriteString(w, ");\n\n")
 }
 
+// isSyntheticRoot reports whether name is one of the message marker structs
+// declared directly in headerPrologue (struct Request {}; etc.) rather than
+// generated from a schema definition.
+func isSyntheticRoot(name string) bool {
+	switch name {
+	case "ProtocolMessage", "Request", "Response", "Event":
+		return true
+	default:
+		return false
+	}
+}
+
+// rootStructType walks name's own allOf $ref chain (and name itself) and
+// reports which of the three synthetic DAP message roots it ultimately
+// derives from, or "" if it isn't a message type at all. This lets
+// buildStructs recognize a request/response/event defined through several
+// levels of allOf composition (e.g. ConcreteRequest -> CancellableRequest ->
+// Request), not just a single direct $ref to Request/Response/Event.
+func (r *root) rootStructType(name string) (structType, error) {
+	switch name {
+	case "Request":
+		return request, nil
+	case "Response":
+		return response, nil
+	case "Event":
+		return event, nil
+	}
+	def, ok := r.Definitions[name]
+	if !ok {
+		return "", fmt.Errorf("Unknown type '%s'", name)
+	}
+	for _, entry := range def.AllOf {
+		if entry.Ref == "" {
+			continue
+		}
+		ref, err := r.getRef(entry.Ref)
+		if err != nil {
+			return "", err
+		}
+		ty, err := r.rootStructType(ref.name)
+		if err != nil {
+			return "", err
+		}
+		if ty != "" {
+			return ty, nil
+		}
+	}
+	return "", nil
+}
+
+// transitiveRefs returns every type name reachable from name's own allOf
+// $ref entries, transitively.
+func (r *root) transitiveRefs(name string) ([]string, error) {
+	def, ok := r.Definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown type '%s'", name)
+	}
+	out := []string{}
+	for _, entry := range def.AllOf {
+		if entry.Ref == "" {
+			continue
+		}
+		ref, err := r.getRef(entry.Ref)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ref.name)
+		more, err := r.transitiveRefs(ref.name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, more...)
+	}
+	return out, nil
+}
+
+// resolveAllOf walks an allOf list of arbitrary length and composition,
+// where each entry is either a $ref to another definition or an inline
+// properties/required block. It linearizes the $ref'd types into a single
+// C++ base chain (C++ has no multiple inheritance here): base is the first
+// $ref'd type, replaced by any later $ref'd type that derives from it. deps
+// holds every other $ref'd type that isn't reachable through the current
+// base's allOf chain when it is seen, every base that was later replaced,
+// and base itself (unless it's a synthetic root), so that emitFiles's
+// dependency walk always declares base before the struct that derives from
+// it. The properties of a $ref'd type outside base's chain are not merged
+// into the struct; it is only recorded in deps. Every inline
+// block's properties, required and (first non-empty) description are merged
+// together and returned as props, required and desc.
+func resolveAllOf(r *root, allOf []definition) (base string, deps []string, desc string, props properties, required []string, err error) {
+	props = properties{}
+	baseAncestors := map[string]bool{}
+
+	promote := func(name string) error {
+		ancestors, err := r.transitiveRefs(name)
+		if err != nil {
+			return err
+		}
+		if base != "" && !isSyntheticRoot(base) {
+			deps = append(deps, base)
+		}
+		base = name
+		baseAncestors = map[string]bool{}
+		for _, a := range ancestors {
+			baseAncestors[a] = true
+		}
+		return nil
+	}
+
+	for _, entry := range allOf {
+		if entry.Ref == "" {
+			if desc == "" {
+				desc = entry.Description
+			}
+			for name, p := range entry.Properties {
+				props[name] = p
+			}
+			required = append(required, entry.Required...)
+			continue
+		}
+
+		ref, refErr := r.getRef(entry.Ref)
+		if refErr != nil {
+			return "", nil, "", nil, nil, refErr
+		}
+
+		switch {
+		case base == "":
+			if err := promote(ref.name); err != nil {
+				return "", nil, "", nil, nil, err
+			}
+		case baseAncestors[ref.name]:
+			// ref is an ancestor of the current base; its fields are
+			// already reachable through the base's own inheritance chain.
+		default:
+			ancestors, aerr := r.transitiveRefs(ref.name)
+			if aerr != nil {
+				return "", nil, "", nil, nil, aerr
+			}
+			moreSpecific := false
+			for _, a := range ancestors {
+				if a == base {
+					moreSpecific = true
+					break
+				}
+			}
+			switch {
+			case moreSpecific:
+				if err := promote(ref.name); err != nil {
+					return "", nil, "", nil, nil, err
+				}
+			case !isSyntheticRoot(ref.name):
+				deps = append(deps, ref.name)
+			}
+		}
+	}
+
+	if base != "" && !isSyntheticRoot(base) {
+		deps = append(deps, base)
+	}
+
+	return base, deps, desc, props, required, nil
+}
+
 func buildStructs(r *root) ([]*cppStruct, error) {
 	ignore := map[string]bool{
 		// These are handled internally.
@@ -399,33 +811,70 @@ func buildStructs(r *root) ([]*cppStruct, error) {
 		}
 
 		base := ""
-		if len(def.AllOf) > 1 && def.AllOf[0].Ref != "" {
-			ref, err := r.getRef(def.AllOf[0].Ref)
+		desc := def.Description
+		mergedProps := def.Properties
+		mergedRequired := def.Required
+		var extraDeps []string
+		if len(def.AllOf) > 0 {
+			var allOfDesc string
+			var allOfProps properties
+			var allOfRequired []string
+			var err error
+			base, extraDeps, allOfDesc, allOfProps, allOfRequired, err = resolveAllOf(r, def.AllOf)
 			if err != nil {
 				return nil, err
 			}
-			base = ref.name
-			if len(def.AllOf) > 2 {
-				return nil, fmt.Errorf("Cannot handle allOf with more than 2 entries")
+			if allOfDesc != "" {
+				desc = allOfDesc
 			}
-			def = def.AllOf[1]
+			mergedProps = allOfProps
+			mergedRequired = allOfRequired
 		}
 
 		s := cppStruct{
-			desc: def.Description,
-			name: defName,
-			base: base,
+			desc:   desc,
+			name:   defName,
+			base:   base,
+			source: def.source,
+			deps:   extraDeps,
+		}
+
+		rootTy := structType("")
+		if base != "" {
+			var err error
+			rootTy, err = r.rootStructType(base)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// A type can derive from Request/Event through an intermediate
+		// abstract base (e.g. RestartRequest -> CancellableRequest ->
+		// Request) that has no "command"/"event" of its own. That
+		// intermediate isn't itself a concrete message: it has no C++
+		// Response to synthesize and nothing to dispatch on, so emit it as
+		// a plain struct (its own concrete descendants still derive from it
+		// via base/deps) rather than mistaking it for a leaf request/event.
+		switch rootTy {
+		case request:
+			if _, ok := mergedProps["command"]; !ok {
+				rootTy = ""
+			}
+		case event:
+			if _, ok := mergedProps["event"]; !ok {
+				rootTy = ""
+			}
 		}
 
 		var props properties
 		var required []string
 		var err error
-		switch base {
-		case "Request":
-			if arguments, ok := def.Properties["arguments"]; ok {
+		switch rootTy {
+		case request:
+			if arguments, ok := mergedProps["arguments"]; ok {
 				props, required, err = arguments.properties(r)
 			}
-			if command, ok := def.Properties["command"]; ok {
+			if command, ok := mergedProps["command"]; ok {
 				s.typename = command.ClosedEnum[0]
 			}
 			response := strings.TrimSuffix(s.name, "Request") + "Response"
@@ -433,24 +882,24 @@ func buildStructs(r *root) ([]*cppStruct, error) {
 			s.typedefs = append(s.typedefs, cppTypedef{"Response", response})
 			s.emit = true
 			s.ty = request
-		case "Response":
-			if body, ok := def.Properties["body"]; ok {
+		case response:
+			if body, ok := mergedProps["body"]; ok {
 				props, required, err = body.properties(r)
 			}
 			s.emit = true
 			s.ty = response
-		case "Event":
-			if body, ok := def.Properties["body"]; ok {
+		case event:
+			if body, ok := mergedProps["body"]; ok {
 				props, required, err = body.properties(r)
 			}
-			if command, ok := def.Properties["event"]; ok {
+			if command, ok := mergedProps["event"]; ok {
 				s.typename = command.ClosedEnum[0]
 			}
 			s.emit = true
 			s.ty = event
 		default:
-			props = def.Properties
-			required = def.Required
+			props = mergedProps
+			required = mergedRequired
 			s.ty = types
 		}
 		if err != nil {
@@ -522,65 +971,186 @@ const (
 	types    = structType("types")
 )
 
-type cppFilePaths map[structType]string
+// fileKey identifies one of the emitted per-structType files: either an
+// upstream file for the given structType (source == ""), or a vendor
+// extension file collecting every struct touched by that source, regardless
+// of structType.
+type fileKey struct {
+	source string
+	ty     structType
+}
 
-type cppFiles map[structType]*os.File
+type filePaths map[fileKey]string
+
+type emittedFiles map[fileKey]*os.File
+
+// structFileKey returns the fileKey that s's implementation (method bodies,
+// typeinfo, etc., as opposed to its declaration) should be written to.
+func structFileKey(s *cppStruct) fileKey {
+	if s.source != "" {
+		return fileKey{source: s.source}
+	}
+	return fileKey{ty: s.ty}
+}
+
+// Backend drives code generation for a single target language from the
+// structs parsed out of the merged schema. A backend is free to interpret
+// "header" loosely: it is simply the single file that every other emitted
+// file for that backend depends on (e.g. protocol.h for C++, or the file
+// declaring the shared interfaces for Go); backends with no such file may
+// return an empty header path from OutputPaths.
+type Backend interface {
+	// Name identifies the backend for the -lang flag.
+	Name() string
+	// OutputPaths returns the header path (or "" if this backend has none)
+	// and the set of per-structType/per-extension file paths to write.
+	OutputPaths(extNames []string) (headerPath string, paths filePaths)
+	// EmitPrologue writes the boilerplate that precedes any struct in the
+	// header and in every file in files.
+	EmitPrologue(h io.Writer, files emittedFiles)
+	// EmitStruct writes s's declaration to h and its implementation to the
+	// appropriate file in files.
+	EmitStruct(h io.Writer, files emittedFiles, s *cppStruct)
+	// EmitEpilogue writes the boilerplate that follows every struct in the
+	// header and in every file in files.
+	EmitEpilogue(h io.Writer, files emittedFiles)
+	// PostProcess runs this backend's formatter (if any) over the header and
+	// every path in paths.
+	PostProcess(headerPath string, paths filePaths) error
+}
+
+var backends = map[string]Backend{
+	"cpp": cppBackend{},
+	"go":  goBackend{},
+}
 
 func run() error {
-	data, err := loadJSONFile()
+	lockPath := lockFilePath()
+	lock, err := loadLockFile(lockPath)
 	if err != nil {
 		return err
 	}
-	r := root{}
-	d := json.NewDecoder(bytes.NewReader(data))
-	if err := d.Decode(&r); err != nil {
-		return err
+
+	if *printLock {
+		data, err := json.MarshalIndent(lock, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	sources := schemas
+	if len(sources) == 0 {
+		sources = schemaFlags{{name: "", src: jsonURL}}
+	}
+
+	merged := &root{Definitions: map[string]definition{}}
+	extNames := []string{}
+	for i, s := range sources {
+		cachePath := ""
+		if i == 0 && s.name == "" {
+			cachePath = *cache
+		}
+
+		fetchSrc := s.src
+		entry, pinned := lock[s.name]
+		if pinned && isURL(s.src) {
+			fetchSrc = pinnedSrc(s.src, entry.Ref)
+		}
+
+		data, err := loadJSONFile(fetchSrc, cachePath)
+		if err != nil {
+			return fmt.Errorf("loading schema '%s': %v", schemaLabel(s.name), err)
+		}
+
+		digest := schemaDigest(data)
+		if !pinned || digest != entry.SHA256 {
+			if !*updateLock {
+				if !pinned {
+					return fmt.Errorf("no pinned entry for schema '%s' in %s; re-run with -update-lock to pin it", schemaLabel(s.name), lockPath)
+				}
+				return fmt.Errorf("schema '%s' digest mismatch: got %s, want %s (pinned in %s); re-run with -update-lock to accept the drift", schemaLabel(s.name), digest, entry.SHA256, lockPath)
+			}
+			ref := refOf(fetchSrc)
+			if isURL(fetchSrc) {
+				resolved, err := resolveCommitSHA(fetchSrc, ref)
+				if err != nil {
+					return fmt.Errorf("pinning schema '%s': %v", schemaLabel(s.name), err)
+				}
+				ref = resolved
+			}
+			lock[s.name] = lockEntry{Ref: ref, SHA256: digest}
+		}
+
+		r := root{}
+		if err := json.NewDecoder(bytes.NewReader(data)).Decode(&r); err != nil {
+			return fmt.Errorf("parsing schema '%s': %v", schemaLabel(s.name), err)
+		}
+		if s.name == "" {
+			merged.Schema, merged.Title, merged.Description, merged.Ty = r.Schema, r.Title, r.Description, r.Ty
+		} else {
+			extNames = append(extNames, s.name)
+		}
+		if err := mergeRoot(merged, &r, s.name); err != nil {
+			return err
+		}
+	}
+
+	if *updateLock {
+		if err := lock.save(lockPath); err != nil {
+			return err
+		}
 	}
 
-	hPath, cppPaths := outputPaths()
-	if err := emitFiles(&r, hPath, cppPaths); err != nil {
+	structs, err := buildStructs(merged)
+	if err != nil {
 		return err
 	}
 
-	if clangfmt, err := exec.LookPath("clang-format"); err == nil {
-		if err := exec.Command(clangfmt, "-i", hPath).Run(); err != nil {
+	for _, langName := range strings.Split(*lang, ",") {
+		langName = strings.TrimSpace(langName)
+		backend, ok := backends[langName]
+		if !ok {
+			return fmt.Errorf("unknown -lang backend '%s'", langName)
+		}
+
+		hPath, paths := backend.OutputPaths(extNames)
+		if err := emitFiles(backend, structs, hPath, paths); err != nil {
 			return err
 		}
-		for _, p := range cppPaths {
-			if err := exec.Command(clangfmt, "-i", p).Run(); err != nil {
-				return err
-			}
+		if err := backend.PostProcess(hPath, paths); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func emitFiles(r *root, hPath string, cppPaths map[structType]string) error {
-	h, err := os.Create(hPath)
-	if err != nil {
-		return err
+// emitFiles writes the header and per-file declarations/implementations for
+// structs using backend, following each struct's dependencies so that a
+// struct is only ever emitted after the structs it refers to.
+func emitFiles(backend Backend, structs []*cppStruct, hPath string, paths filePaths) error {
+	var h io.Writer = ioutil.Discard
+	if hPath != "" {
+		f, err := os.Create(hPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h = f
 	}
-	defer h.Close()
-	cppFiles := map[structType]*os.File{}
-	for ty, p := range cppPaths {
+	files := emittedFiles{}
+	for key, p := range paths {
 		f, err := os.Create(p)
 		if err != nil {
 			return err
 		}
-		cppFiles[ty] = f
+		files[key] = f
 		defer f.Close()
 	}
 
-	h.WriteString(headerPrologue)
-	for _, f := range cppFiles {
-		f.WriteString(cppPrologue)
-	}
-
-	structs, err := buildStructs(r)
-	if err != nil {
-		return err
-	}
+	backend.EmitPrologue(h, files)
 
 	structsByName := map[string]*cppStruct{}
 	for _, s := range structs {
@@ -595,10 +1165,14 @@ func emitFiles(r *root, hPath string, cppPaths map[structType]string) error {
 		}
 		seen[s.name] = true
 		for _, dep := range s.deps {
-			emit(structsByName[dep])
+			// dep may not resolve to a struct, e.g. the <Name>Response that
+			// buildStructs names for a request whose schema defines no such
+			// response; skip it rather than dereferencing a nil struct.
+			if d := structsByName[dep]; d != nil {
+				emit(d)
+			}
 		}
-		s.writeHeader(h)
-		s.writeCPP(cppFiles[s.ty])
+		backend.EmitStruct(h, files, s)
 	}
 
 	// emit message types.
@@ -610,44 +1184,261 @@ func emitFiles(r *root, hPath string, cppPaths map[structType]string) error {
 		}
 	}
 
-	h.WriteString(headerEpilogue)
-	for _, f := range cppFiles {
-		f.WriteString(cppEpilogue)
-	}
+	backend.EmitEpilogue(h, files)
 
 	return nil
 }
 
-func loadJSONFile() ([]byte, error) {
-	if *cache != "" {
-		data, err := ioutil.ReadFile(*cache)
+// loadJSONFile loads a single schema from src, which is either an http(s) URL
+// or a local file path. cachePath, if non-empty, is checked first and
+// (for URL sources) populated after a successful fetch.
+func loadJSONFile(src string, cachePath string) ([]byte, error) {
+	if cachePath != "" {
+		data, err := ioutil.ReadFile(cachePath)
 		if err == nil {
 			return data, nil
 		}
 	}
-	resp, err := http.Get(jsonURL)
+
+	if !isURL(src) {
+		return ioutil.ReadFile(src)
+	}
+
+	resp, err := http.Get(src)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
-	if *cache != "" {
-		ioutil.WriteFile(*cache, data, 0777)
+	if cachePath != "" {
+		ioutil.WriteFile(cachePath, data, 0777)
 	}
 	return data, nil
 }
 
-func outputPaths() (string, cppFilePaths) {
+// thisDir is the directory containing protocol_gen.go itself, used by
+// backends to locate their output relative to the tool rather than the
+// caller's current working directory.
+func thisDir() string {
 	_, thisFile, _, _ := runtime.Caller(1)
-	thisDir := path.Dir(thisFile)
-	h := path.Join(thisDir, "../../include/dap/protocol.h")
-	cpp := cppFilePaths{
-		request:  path.Join(thisDir, "../../src/protocol_requests.cpp"),
-		response: path.Join(thisDir, "../../src/protocol_response.cpp"),
-		event:    path.Join(thisDir, "../../src/protocol_events.cpp"),
-		types:    path.Join(thisDir, "../../src/protocol_types.cpp"),
-	}
-	return h, cpp
+	return path.Dir(thisFile)
+}
+
+// cppBackend is the original backend: it emits the cppdap protocol.h header
+// plus one protocol_<structType>.cpp per upstream structType, and one
+// protocol_ext_<name>.cpp per vendor extension schema.
+type cppBackend struct{}
+
+func (cppBackend) Name() string { return "cpp" }
+
+func (cppBackend) OutputPaths(extNames []string) (string, filePaths) {
+	dir := thisDir()
+	h := path.Join(dir, "../../include/dap/protocol.h")
+	paths := filePaths{
+		{ty: request}:  path.Join(dir, "../../src/protocol_requests.cpp"),
+		{ty: response}: path.Join(dir, "../../src/protocol_response.cpp"),
+		{ty: event}:    path.Join(dir, "../../src/protocol_events.cpp"),
+		{ty: types}:    path.Join(dir, "../../src/protocol_types.cpp"),
+	}
+	for _, name := range extNames {
+		paths[fileKey{source: name}] = path.Join(dir, fmt.Sprintf("../../src/protocol_ext_%s.cpp", name))
+	}
+	return h, paths
+}
+
+func (cppBackend) EmitPrologue(h io.Writer, files emittedFiles) {
+	io.WriteString(h, headerPrologue)
+	for _, f := range files {
+		io.WriteString(f, cppPrologue)
+	}
+}
+
+func (cppBackend) EmitStruct(h io.Writer, files emittedFiles, s *cppStruct) {
+	s.writeHeader(h)
+	s.writeCPP(files[structFileKey(s)])
+}
+
+func (cppBackend) EmitEpilogue(h io.Writer, files emittedFiles) {
+	io.WriteString(h, headerEpilogue)
+	for _, f := range files {
+		io.WriteString(f, cppEpilogue)
+	}
+}
+
+func (cppBackend) PostProcess(hPath string, paths filePaths) error {
+	clangfmt, err := exec.LookPath("clang-format")
+	if err != nil {
+		// clang-format isn't required; the generated files are just
+		// unformatted in that case, as before.
+		return nil
+	}
+	if err := exec.Command(clangfmt, "-i", hPath).Run(); err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := exec.Command(clangfmt, "-i", p).Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	goCommonPrologue = `// Copyright 2019 Google LLC
+	//
+	// Licensed under the Apache License, Version 2.0 (the "License");
+	// you may not use this file except in compliance with the License.
+	// You may obtain a copy of the License at
+	//
+	//     https://www.apache.org/licenses/LICENSE-2.0
+	//
+	// Unless required by applicable law or agreed to in writing, software
+	// distributed under the License is distributed on an "AS IS" BASIS,
+	// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	// See the License for the specific language governing permissions and
+	// limitations under the License.
+
+	// Generated with protocol_gen.go -- do not edit this file.
+	//   go run tools/protocol_gen/protocol_gen.go -lang=go
+`
+
+	goHeaderPrologue = goCommonPrologue + `
+package dap
+
+// Request is implemented by every generated DAP request type.
+type Request interface{ isRequest() }
+
+// Response is implemented by every generated DAP response type.
+type Response interface{ isResponse() }
+
+// Event is implemented by every generated DAP event type.
+type Event interface{ isEvent() }
+
+`
+
+	goImplPrologue = goCommonPrologue + `
+package dap
+
+`
+)
+
+// goBackend emits a "package dap" Go source tree mirroring the structs
+// generated for cppBackend: struct types with json tags reflecting the
+// DAP_FIELD names, optional properties as pointer fields, and a
+// Request/Response/Event interface in place of DAP_DECLARE_STRUCT_TYPEINFO.
+type goBackend struct{}
+
+func (goBackend) Name() string { return "go" }
+
+func (goBackend) OutputPaths(extNames []string) (string, filePaths) {
+	dir := path.Join(thisDir(), "../../bindings/go/dap")
+	h := path.Join(dir, "protocol.go")
+	paths := filePaths{
+		{ty: request}:  path.Join(dir, "protocol_requests.go"),
+		{ty: response}: path.Join(dir, "protocol_response.go"),
+		{ty: event}:    path.Join(dir, "protocol_events.go"),
+		{ty: types}:    path.Join(dir, "protocol_types.go"),
+	}
+	for _, name := range extNames {
+		paths[fileKey{source: name}] = path.Join(dir, fmt.Sprintf("protocol_ext_%s.go", name))
+	}
+	return h, paths
+}
+
+func (goBackend) EmitPrologue(h io.Writer, files emittedFiles) {
+	io.WriteString(h, goHeaderPrologue)
+	for _, f := range files {
+		io.WriteString(f, goImplPrologue)
+	}
+}
+
+func (goBackend) EmitStruct(h io.Writer, files emittedFiles, s *cppStruct) {
+	if s.desc != "" {
+		io.WriteString(h, "// "+strings.ReplaceAll(s.desc, "\n", "\n// ")+"\n")
+	}
+	io.WriteString(h, "type "+s.name+" struct {\n")
+	if s.base != "" && !isSyntheticRoot(s.base) {
+		// Synthetic roots (Request/Response/Event) are Go interfaces, not
+		// structs; embedding one would add a nil interface field that
+		// marshals as e.g. "Request": null. Message-type tagging for those
+		// is done instead via the isRequest()/isResponse()/isEvent() methods
+		// below.
+		io.WriteString(h, "\t"+s.base+"\n\n")
+	}
+	for _, f := range s.fields {
+		if f.desc != "" {
+			io.WriteString(h, "\t// "+strings.ReplaceAll(f.desc, "\n", "\n\t// ")+"\n")
+		}
+		ty := goFieldType(f.ty)
+		if f.optional {
+			ty = "*" + ty
+		}
+		io.WriteString(h, "\t"+goFieldName(f.name)+" "+ty+" `json:\""+f.name+",omitempty\"`\n")
+	}
+	io.WriteString(h, "}\n\n")
+
+	w := files[structFileKey(s)]
+	switch s.ty {
+	case request:
+		io.WriteString(w, "func (*"+s.name+") isRequest() {}\n\n")
+	case response:
+		io.WriteString(w, "func (*"+s.name+") isResponse() {}\n\n")
+	case event:
+		io.WriteString(w, "func (*"+s.name+") isEvent() {}\n\n")
+	}
+}
+
+func (goBackend) EmitEpilogue(h io.Writer, files emittedFiles) {}
+
+func (goBackend) PostProcess(hPath string, paths filePaths) error {
+	gofmt, err := exec.LookPath("gofmt")
+	if err != nil {
+		// gofmt isn't required; the generated files are just unformatted in
+		// that case, as with cppBackend and clang-format.
+		return nil
+	}
+	args := []string{"-w", hPath}
+	for _, p := range paths {
+		args = append(args, p)
+	}
+	return exec.Command(gofmt, args...).Run()
+}
+
+// goFieldType maps a parsed property type (as produced by typed.typename) to
+// its Go equivalent. Struct-typed properties keep their dap type name, since
+// goBackend emits one Go struct per cppStruct using the same names.
+func goFieldType(ty string) string {
+	switch {
+	case strings.HasPrefix(ty, "array<") && strings.HasSuffix(ty, ">"):
+		return "[]" + goFieldType(strings.TrimSuffix(strings.TrimPrefix(ty, "array<"), ">"))
+	case strings.HasPrefix(ty, "variant<"):
+		return "interface{}"
+	}
+	switch ty {
+	case "string":
+		return "string"
+	case "integer":
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "object", "any", "null":
+		return "interface{}"
+	default:
+		return ty
+	}
+}
+
+// goFieldName turns a DAP property name (e.g. "threadId") into an exported
+// Go field name (e.g. "ThreadId").
+func goFieldName(name string) string {
+	name = sanitize(name)
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
 }
\ No newline at end of file